@@ -0,0 +1,55 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package composed implements a downloader.Downloader that walks a list of backends in
+// order, falling back to the next on downloader.ErrNotFound.
+package composed
+
+import (
+	"context"
+	"errors"
+
+	"github.com/elastic/elastic-agent/dev-tools/mage/manifest/downloader"
+)
+
+// Downloader tries each backend in Backends in turn, returning the first successful fetch.
+type Downloader struct {
+	Backends []downloader.Downloader
+}
+
+// New returns a Downloader that tries backends in the given order.
+func New(backends ...downloader.Downloader) *Downloader {
+	return &Downloader{Backends: backends}
+}
+
+func (d *Downloader) Fetch(ctx context.Context, url, sha512 string, onBytes func(n int64)) (string, error) {
+	var lastErr error
+	for _, backend := range d.Backends {
+		path, err := backend.Fetch(ctx, url, sha512, onBytes)
+		if err == nil {
+			return path, nil
+		}
+		if !errors.Is(err, downloader.ErrNotFound) {
+			return "", err
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = downloader.ErrNotFound
+	}
+	return "", lastErr
+}
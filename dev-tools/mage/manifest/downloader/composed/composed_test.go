@@ -0,0 +1,90 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package composed
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/elastic/elastic-agent/dev-tools/mage/manifest/downloader"
+)
+
+type fakeBackend struct {
+	path    string
+	err     error
+	onBytes func(n int64)
+}
+
+func (f *fakeBackend) Fetch(_ context.Context, _, _ string, onBytes func(n int64)) (string, error) {
+	f.onBytes = onBytes
+	if onBytes != nil {
+		onBytes(1)
+	}
+	return f.path, f.err
+}
+
+func TestFetchFallsBackOnNotFound(t *testing.T) {
+	first := &fakeBackend{err: downloader.ErrNotFound}
+	second := &fakeBackend{path: "/cache/artifact.tar.gz"}
+	d := New(first, second)
+
+	path, err := d.Fetch(context.Background(), "https://example.test/artifact.tar.gz", "deadbeef", nil)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if path != second.path {
+		t.Fatalf("expected fallback path %q, got %q", second.path, path)
+	}
+}
+
+func TestFetchPropagatesNonNotFoundError(t *testing.T) {
+	wantErr := errors.New("boom")
+	first := &fakeBackend{err: wantErr}
+	second := &fakeBackend{path: "/cache/artifact.tar.gz"}
+	d := New(first, second)
+
+	_, err := d.Fetch(context.Background(), "https://example.test/artifact.tar.gz", "deadbeef", nil)
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+}
+
+func TestFetchReturnsNotFoundWhenAllBackendsMiss(t *testing.T) {
+	first := &fakeBackend{err: downloader.ErrNotFound}
+	second := &fakeBackend{err: downloader.ErrNotFound}
+	d := New(first, second)
+
+	_, err := d.Fetch(context.Background(), "https://example.test/artifact.tar.gz", "deadbeef", nil)
+	if !errors.Is(err, downloader.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestFetchPassesOnBytesThrough(t *testing.T) {
+	backend := &fakeBackend{path: "/cache/artifact.tar.gz"}
+	d := New(backend)
+
+	var got int64
+	if _, err := d.Fetch(context.Background(), "https://example.test/artifact.tar.gz", "deadbeef", func(n int64) { got = n }); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if got != 1 {
+		t.Fatalf("expected onBytes to be invoked with 1, got %d", got)
+	}
+}
@@ -0,0 +1,53 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package downloader defines the Downloader interface that DownloadComponentsFromManifest
+// uses to fetch build artifacts, along with the fs, http and composed implementations.
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned by a Downloader that has no knowledge of the requested artifact. A
+// composed.Downloader treats it as a signal to fall back to the next backend in its list
+// rather than failing outright.
+var ErrNotFound = errors.New("artifact not found")
+
+// HTTPStatusError carries the HTTP status code of a failed request so callers (in particular
+// manifest.isRetryable) can tell a retryable transport hiccup (5xx, 408, 429) apart from a
+// permanent failure (any other 4xx). It lives here, rather than in the manifest package that
+// consumes it, so the http Downloader can return it without an import cycle.
+type HTTPStatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d fetching %s", e.StatusCode, e.URL)
+}
+
+// Downloader fetches the artifact at url, whose contents are expected to hash to sha512, and
+// returns the local filesystem path it was placed at (or already existed at). onBytes, if
+// non-nil, is called one or more times during the fetch with the cumulative number of bytes
+// written so far, so a caller can drive a live progress bar; a backend that serves the
+// artifact without copying bytes (e.g. a cache hit) may not call it at all.
+type Downloader interface {
+	Fetch(ctx context.Context, url, sha512 string, onBytes func(n int64)) (string, error)
+}
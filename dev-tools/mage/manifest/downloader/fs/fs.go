@@ -0,0 +1,79 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package fs implements a downloader.Downloader that serves artifacts out of a local
+// directory, letting CI runners pre-seed a shared cache and skip the network entirely.
+package fs
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/elastic/elastic-agent/dev-tools/mage/manifest/downloader"
+)
+
+// Downloader looks artifacts up under Dir, keyed by the sha512 the caller expects them to
+// have and their filename, so a stale or unrelated file with the same name never matches.
+type Downloader struct {
+	// Dir is the root of the local artifact cache.
+	Dir string
+	// AllowedHosts mirrors manifest.AllowedManifestHosts: a lookup is only served when the
+	// requested URL's host is one of these. The cache keeps no record of where a cached file
+	// came from; only the requested URL is checked.
+	AllowedHosts []string
+}
+
+// New returns a Downloader rooted at dir, serving only lookups whose requested URL host is
+// in allowedHosts.
+func New(dir string, allowedHosts []string) *Downloader {
+	return &Downloader{Dir: dir, AllowedHosts: allowedHosts}
+}
+
+// Fetch returns the cached path for url if present under Dir, keyed by sha512. It never
+// downloads anything itself, so onBytes is never called - there are no bytes in flight to
+// report.
+func (d *Downloader) Fetch(_ context.Context, rawURL, sha512 string, _ func(n int64)) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing %s: %w", rawURL, err)
+	}
+	if !d.hostAllowed(parsed.Host) {
+		return "", fmt.Errorf("origin host %s not allowed: %w", parsed.Host, downloader.ErrNotFound)
+	}
+	if sha512 == "" {
+		return "", fmt.Errorf("no expected sha512 to key the cache lookup with: %w", downloader.ErrNotFound)
+	}
+
+	filename := filepath.Base(parsed.Path)
+	candidate := filepath.Join(d.Dir, sha512, filename)
+	if _, err := os.Stat(candidate); err != nil {
+		return "", fmt.Errorf("%s not present in local cache %s: %w", filename, d.Dir, downloader.ErrNotFound)
+	}
+	return candidate, nil
+}
+
+func (d *Downloader) hostAllowed(host string) bool {
+	for _, allowed := range d.AllowedHosts {
+		if allowed == host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package http implements a downloader.Downloader that fetches artifacts over HTTPS, the
+// same behavior DownloadComponentsFromManifest always had before the Downloader interface
+// existed.
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/elastic/elastic-agent/dev-tools/mage/manifest/downloader"
+)
+
+// Downloader fetches artifacts over HTTPS into Dir.
+type Downloader struct {
+	// Dir is the directory downloaded artifacts are written to.
+	Dir string
+}
+
+// New returns a Downloader that writes fetched artifacts into dir.
+func New(dir string) *Downloader {
+	return &Downloader{Dir: dir}
+}
+
+// Fetch downloads url into Dir and returns the path it was written to. A single failed
+// attempt is returned as-is; retrying is the caller's responsibility. onBytes, if non-nil, is
+// called as the response body is copied to disk, with the cumulative bytes written so far.
+func (d *Downloader) Fetch(ctx context.Context, rawURL, _ string, onBytes func(n int64)) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing %s: %w", rawURL, err)
+	}
+	target := filepath.Join(d.Dir, filepath.Base(parsed.Path))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("building request for %s: %w", rawURL, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetching %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &downloader.HTTPStatusError{StatusCode: resp.StatusCode, URL: rawURL}
+	}
+
+	out, err := os.Create(target)
+	if err != nil {
+		return "", fmt.Errorf("creating %s: %w", target, err)
+	}
+	defer out.Close()
+	var w io.Writer = out
+	if onBytes != nil {
+		w = &progressWriter{w: out, onBytes: onBytes}
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return "", fmt.Errorf("writing %s: %w", target, err)
+	}
+	return target, nil
+}
+
+// progressWriter wraps an io.Writer, calling onBytes with the cumulative number of bytes
+// written after each Write so a caller copying a large response body can drive a live
+// progress bar instead of only learning the final size once the copy completes.
+type progressWriter struct {
+	w       io.Writer
+	n       int64
+	onBytes func(n int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.n += int64(n)
+	p.onBytes(p.n)
+	return n, err
+}
@@ -0,0 +1,93 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchReportsCumulativeBytes(t *testing.T) {
+	const payload = "the quick brown fox jumps over the lazy dog"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(payload))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	d := New(dir)
+
+	var seen []int64
+	target, err := d.Fetch(context.Background(), srv.URL+"/artifact.tar.gz", "", func(n int64) {
+		seen = append(seen, n)
+	})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if filepath.Dir(target) != dir {
+		t.Fatalf("expected artifact under %s, got %s", dir, target)
+	}
+	if len(seen) == 0 {
+		t.Fatal("expected onBytes to be called at least once")
+	}
+	for i := 1; i < len(seen); i++ {
+		if seen[i] < seen[i-1] {
+			t.Fatalf("expected cumulative bytes to be non-decreasing, got %v", seen)
+		}
+	}
+	if last := seen[len(seen)-1]; last != int64(len(payload)) {
+		t.Fatalf("expected final reported bytes %d, got %d", len(payload), last)
+	}
+
+	contents, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("reading fetched artifact: %v", err)
+	}
+	if !strings.EqualFold(string(contents), payload) {
+		t.Fatalf("unexpected artifact contents: %q", contents)
+	}
+}
+
+func TestFetchWithoutOnBytesCallback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("payload"))
+	}))
+	defer srv.Close()
+
+	d := New(t.TempDir())
+	if _, err := d.Fetch(context.Background(), srv.URL+"/artifact.tar.gz", "", nil); err != nil {
+		t.Fatalf("Fetch with nil onBytes: %v", err)
+	}
+}
+
+func TestFetchNonOKStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	d := New(t.TempDir())
+	if _, err := d.Fetch(context.Background(), srv.URL+"/artifact.tar.gz", "", nil); err == nil {
+		t.Fatal("expected an error for a non-200 response")
+	}
+}
@@ -22,51 +22,55 @@ import (
 	"errors"
 	"fmt"
 	"github.com/elastic/elastic-agent/pkg/testing/tools"
+	"io"
 	"log"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
-	"time"
 
 	"github.com/magefile/mage/mg"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 )
 
-// A backoff schedule for when and how often to retry failed HTTP
-// requests. The first element is the time to wait after the
-// first failure, the second the time to wait after the second
-// failure, etc. After reaching the last element, retries stop
-// and the request is considered failed.
-var backoffSchedule = []time.Duration{
-	1 * time.Second,
-	3 * time.Second,
-	10 * time.Second,
-}
-
 var invalidManifestURL = errors.New("invalid ManifestURL provided")
 var notAllowedManifestURL = errors.New("the provided ManifestURL is not allowed URL")
 var AllowedManifestHosts = []string{"snapshots.elastic.co", "staging.elastic.co"}
 
-// DownloadManifest is going to download the given manifest file and return the ManifestResponse
-func DownloadManifest(manifest string) (tools.Build, error) {
-	manifestUrl, urlError := url.Parse(manifest)
-	if urlError != nil {
-		return tools.Build{}, invalidManifestURL
+// sanitizeAllowedURL validates rawURL against AllowedManifestHosts and, if it passes, returns
+// it rewritten as https://<host><path>, stripping any query/fragment the manifest may have
+// added. Every URL this package fetches - the manifest itself, an artifact, or its sha512/asc
+// sidecars - goes through this same check, so a compromised manifest can't redirect any of
+// them to an attacker-controlled host.
+func sanitizeAllowedURL(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", invalidManifestURL
 	}
 	var valid = false
-	for _, manifestHost := range AllowedManifestHosts {
-		if manifestHost == manifestUrl.Host {
+	for _, allowedHost := range AllowedManifestHosts {
+		if allowedHost == parsed.Host {
 			valid = true
 		}
 	}
 	if !valid {
-		log.Printf("Not allowed %s, valid ones are %+v", manifestUrl.Host, AllowedManifestHosts)
-		return tools.Build{}, notAllowedManifestURL
+		log.Printf("Not allowed %s, valid ones are %+v", parsed.Host, AllowedManifestHosts)
+		return "", notAllowedManifestURL
+	}
+	return fmt.Sprintf("https://%s%s", parsed.Host, parsed.Path), nil
+}
+
+// DownloadManifest is going to download the given manifest file and return the ManifestResponse.
+// retry controls the backoff schedule used if the download fails; pass RetryConfig{} for the
+// defaults.
+func DownloadManifest(ctx context.Context, manifest string, retry RetryConfig) (tools.Build, error) {
+	sanitizedUrl, err := sanitizeAllowedURL(manifest)
+	if err != nil {
+		return tools.Build{}, err
 	}
-	sanitizedUrl := fmt.Sprintf("https://%s%s", manifestUrl.Host, manifestUrl.Path)
 	f := func() (tools.Build, error) { return downloadManifestData(sanitizedUrl) }
-	manifestResponse, err := doWithRetries(f)
+	manifestResponse, err := doWithRetries(ctx, retry, sanitizedUrl, f)
 	if err != nil {
 		return tools.Build{}, fmt.Errorf("downloading manifest: %w", err)
 	}
@@ -77,8 +81,16 @@ func DownloadManifest(manifest string) (tools.Build, error) {
 	return manifestResponse, nil
 }
 
-func resolveManifestPackage(project tools.Project, pkg string, reqPackage string, version string) []string {
-	packageName := fmt.Sprintf("%s-%s-%s", pkg, version, reqPackage)
+// resolveManifestPackage looks up pkg's manifest entry for reqPackage/version in project.
+// artifact, when non-empty, is used as the filename prefix instead of pkg, for components
+// whose filename prefix in the manifest differs from both their project key and their
+// Packages entry.
+func resolveManifestPackage(project tools.Project, pkg string, artifact string, reqPackage string, version string) []string {
+	filenamePrefix := pkg
+	if artifact != "" {
+		filenamePrefix = artifact
+	}
+	packageName := fmt.Sprintf("%s-%s-%s", filenamePrefix, version, reqPackage)
 	val, ok := project.Packages[packageName]
 	if !ok {
 		return nil
@@ -92,77 +104,156 @@ func resolveManifestPackage(project tools.Project, pkg string, reqPackage string
 
 // DownloadComponentsFromManifest is going to download a set of components from the given manifest into the destination
 // dropPath folder in order to later use that folder for packaging
-func DownloadComponentsFromManifest(manifest string, platforms []string, platformPackages map[string]string, dropPath string) error {
-	componentSpec := map[string][]string{
-		"apm-server":            {"apm-server"},
-		"beats":                 {"auditbeat", "filebeat", "heartbeat", "metricbeat", "osquerybeat", "packetbeat"},
-		"cloud-defend":          {"cloud-defend"},
-		"cloudbeat":             {"cloudbeat"},
-		"elastic-agent-shipper": {"elastic-agent-shipper"},
-		"endpoint-dev":          {"endpoint-security"},
-		"fleet-server":          {"fleet-server"},
-		"prodfiler":             {"pf-elastic-collector", "pf-elastic-symbolizer", "pf-host-agent"},
-	}
-
-	manifestResponse, err := DownloadManifest(manifest)
+func DownloadComponentsFromManifest(manifest string, platforms []string, platformPackages map[string]string, dropPath string, opts Options) error {
+	componentSpecs, err := opts.loadComponentSpecs()
+	if err != nil {
+		return fmt.Errorf("loading component spec: %w", err)
+	}
+
+	ctx := context.Background()
+	manifestResponse, err := DownloadManifest(ctx, manifest, opts.Retry)
 	if err != nil {
 		return fmt.Errorf("failed to download remote manifest file %w", err)
 	}
 	projects := manifestResponse.Projects
 
-	errGrp, downloadsCtx := errgroup.WithContext(context.Background())
-	for component, pkgs := range componentSpec {
+	errGrp, downloadsCtx := errgroup.WithContext(ctx)
+	sem := semaphore.NewWeighted(int64(opts.concurrency()))
+	progress := opts.progressOrDefault()
+	// syncErr holds a synchronous (caller/manifest bug) failure that aborts the scheduling
+	// loops below. It is only returned after errGrp.Wait(), so downloads already scheduled
+	// onto errGrp get a chance to finish instead of being abandoned mid-write into dropPath.
+	var syncErr error
+componentLoop:
+	for _, spec := range componentSpecs.Components {
 		for _, platform := range platforms {
+			if !spec.appliesToPlatform(platform) {
+				continue
+			}
 			targetPath := filepath.Join(dropPath)
-			err := os.MkdirAll(targetPath, 0755)
-			if err != nil {
-				return fmt.Errorf("failed to create directory %s", targetPath)
+			if err := os.MkdirAll(targetPath, 0755); err != nil {
+				syncErr = fmt.Errorf("failed to create directory %s", targetPath)
+				break componentLoop
 			}
 			if mg.Verbose() {
-				log.Printf(">>>>>>> Prepare to download project [%s] for [%s]", component, platform)
+				log.Printf(">>>>>>> Prepare to download project [%s] for [%s]", spec.Project, platform)
 			}
-			for _, pkg := range pkgs {
+			for _, pkg := range spec.Packages {
 				reqPackage := platformPackages[platform]
-				pkgURL := resolveManifestPackage(projects[component], pkg, reqPackage, manifestResponse.Version)
-				if pkgURL != nil {
-					for _, p := range pkgURL {
-						log.Printf(">>>>>>>>> Downloading [%s] [%s] ", pkg, p)
-						pkgFilename := path.Base(p)
-						downloadTarget := filepath.Join(targetPath, pkgFilename)
-						if _, err := os.Stat(downloadTarget); err != nil {
-							func(downloadUrl string, target string) {
-								parsedURL, errorUrl := url.Parse(downloadUrl)
-								if errorUrl != nil {
-									errGrp.Go(func() error { return invalidManifestURL })
-								}
-								var valid = false
-								for _, manifestHost := range AllowedManifestHosts {
-									if manifestHost == parsedURL.Host {
-										valid = true
-									}
-								}
-								if !valid {
-									log.Printf("Not allowed %s, valid ones are %+v", parsedURL.Host, AllowedManifestHosts)
-									errGrp.Go(func() error { return notAllowedManifestURL })
-								}
-								cleanUrl := fmt.Sprintf("https://%s%s", parsedURL.Host, parsedURL.Path)
-								download := func() (string, error) { return downloadFile(downloadsCtx, cleanUrl, target) }
-								errGrp.Go(func() error { _, err := doWithRetries(download); return err })
-							}(p, downloadTarget)
-						}
+				pkgURLs := resolveManifestPackage(projects[spec.Project], pkg, spec.Artifact, reqPackage, manifestResponse.Version)
+				if pkgURLs == nil {
+					if spec.Required {
+						syncErr = fmt.Errorf("required component [%s] missing package [%s] for platform [%s]", spec.Project, pkg, platform)
+						break componentLoop
 					}
-				} else if mg.Verbose() {
-					log.Printf(">>>>>>>>> Project [%s] does not have [%s] ", pkg, platform)
+					if mg.Verbose() {
+						log.Printf(">>>>>>>>> Project [%s] does not have [%s] ", pkg, platform)
+					}
+					continue
+				}
+				pkgURL, shaURL, ascURL := pkgURLs[0], pkgURLs[1], pkgURLs[2]
+				pkgFilename := path.Base(pkgURL)
+				downloadTarget := filepath.Join(targetPath, pkgFilename)
+				if _, err := os.Stat(downloadTarget); err == nil {
+					continue
+				}
+
+				// Validated synchronously, before anything is scheduled onto errGrp: an
+				// invalid URL or disallowed host is a caller/manifest bug, not a transport
+				// failure, and should fail immediately rather than race with in-flight
+				// downloads for an error the caller may never see. The sha512/asc sidecar
+				// URLs go through the same allow-list check as the artifact itself, since
+				// they come from the same manifest and are just as capable of pointing
+				// somewhere unintended. Any of these failing aborts the loops via syncErr
+				// rather than returning directly, so downloads already scheduled onto errGrp
+				// are waited on below instead of left running unobserved.
+				cleanUrl, err := sanitizeAllowedURL(pkgURL)
+				if err != nil {
+					syncErr = err
+					break componentLoop
 				}
+				cleanShaURL, err := sanitizeAllowedURL(shaURL)
+				if err != nil {
+					syncErr = err
+					break componentLoop
+				}
+				cleanAscURL, err := sanitizeAllowedURL(ascURL)
+				if err != nil {
+					syncErr = err
+					break componentLoop
+				}
+
+				log.Printf(">>>>>>>>> Downloading [%s] [%s] ", pkg, cleanUrl)
+				dl := opts.downloaderFor(targetPath)
+				func(name, downloadUrl, shaURL, ascURL, target string) {
+					errGrp.Go(func() error {
+						if err := sem.Acquire(downloadsCtx, 1); err != nil {
+							return err
+						}
+						defer sem.Release(1)
+
+						progress.Started(name, downloadUrl)
+						// Resolved first so the fs backend can key its cache lookup by the
+						// artifact's expected checksum rather than just its filename.
+						shaFetch := func() (string, error) { return fetchExpectedSha512(downloadsCtx, shaURL) }
+						expectedSha, err := doWithRetries(downloadsCtx, opts.Retry, shaURL, shaFetch)
+						if err != nil {
+							progress.Failed(name, downloadUrl, err)
+							return fmt.Errorf("resolving expected sha512 for %s: %w", downloadUrl, err)
+						}
+						onBytes := func(n int64) { progress.Bytes(name, downloadUrl, n) }
+						fetch := func() (string, error) { return dl.Fetch(downloadsCtx, downloadUrl, expectedSha, onBytes) }
+						fetchedPath, err := doWithRetries(downloadsCtx, opts.Retry, downloadUrl, fetch)
+						if err != nil {
+							progress.Failed(name, downloadUrl, err)
+							return err
+						}
+						if fetchedPath != target {
+							if err := copyArtifact(fetchedPath, target); err != nil {
+								progress.Failed(name, downloadUrl, err)
+								return fmt.Errorf("staging %s into %s: %w", fetchedPath, target, err)
+							}
+						}
+						// The sha512/asc sidecars are always re-fetched here rather than read
+						// from dropPath, otherwise a poisoned local cache would validate
+						// against itself.
+						if err := verifyDownloadedArtifact(downloadsCtx, target, shaURL, ascURL, opts.Retry, opts.Verify); err != nil {
+							progress.Failed(name, downloadUrl, err)
+							return err
+						}
+						progress.Completed(name, downloadUrl)
+						return nil
+					})
+				}(pkg, cleanUrl, cleanShaURL, cleanAscURL, downloadTarget)
 			}
 		}
 	}
 
-	err = errGrp.Wait()
-	if err != nil {
-		return fmt.Errorf("error downloading files: %w", err)
+	waitErr := errGrp.Wait()
+	if syncErr != nil {
+		return syncErr
+	}
+	if waitErr != nil {
+		return fmt.Errorf("error downloading files: %w", waitErr)
 	}
 
 	log.Printf("Downloads for manifest %q complete.", manifest)
 	return nil
 }
+
+// copyArtifact copies an artifact a Downloader resolved outside of dropPath (e.g. from a
+// shared fs cache) into target.
+func copyArtifact(src, target string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,65 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package manifest
+
+import (
+	"github.com/elastic/elastic-agent/dev-tools/mage/manifest/downloader"
+	"github.com/elastic/elastic-agent/dev-tools/mage/manifest/downloader/composed"
+	"github.com/elastic/elastic-agent/dev-tools/mage/manifest/downloader/fs"
+	httpdownloader "github.com/elastic/elastic-agent/dev-tools/mage/manifest/downloader/http"
+)
+
+// Options configures DownloadComponentsFromManifest beyond the manifest URL, platform list
+// and drop path every caller already needs to supply.
+type Options struct {
+	// Verify controls checksum and signature verification of downloaded artifacts.
+	Verify VerifyOptions
+	// Downloader, when set, overrides the default composed{fs, http} downloader. Useful for
+	// tests or for distributions with their own artifact mirrors.
+	Downloader downloader.Downloader
+	// CacheDir, when set, is checked for pre-seeded artifacts (keyed by sha512) before
+	// falling back to downloading over HTTPS. CI runners with a shared NFS/CI cache can set
+	// this to skip the network entirely.
+	CacheDir string
+	// Concurrency bounds how many artifacts are fetched at once. Defaults to
+	// runtime.NumCPU()*2.
+	Concurrency int
+	// Progress, when set, receives download lifecycle events for each artifact.
+	Progress Progress
+	// Retry controls the backoff schedule used when retrying a failed download. The zero
+	// value uses sensible defaults.
+	Retry RetryConfig
+	// SpecFile, when set, overrides the embedded default components.spec.yml, letting
+	// downstream distributions add or remove bundled components without forking.
+	SpecFile string
+}
+
+// downloaderFor resolves the Downloader to use for a run when the caller hasn't overridden
+// it: a composed downloader over an fs backend rooted at CacheDir (only when CacheDir is set)
+// followed by an http backend writing into dropPath.
+func (o Options) downloaderFor(dropPath string) downloader.Downloader {
+	if o.Downloader != nil {
+		return o.Downloader
+	}
+	var backends []downloader.Downloader
+	if o.CacheDir != "" {
+		backends = append(backends, fs.New(o.CacheDir, AllowedManifestHosts))
+	}
+	backends = append(backends, httpdownloader.New(dropPath))
+	return composed.New(backends...)
+}
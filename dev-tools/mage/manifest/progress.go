@@ -0,0 +1,58 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package manifest
+
+import "runtime"
+
+// Progress receives download lifecycle events for each artifact DownloadComponentsFromManifest
+// fetches, so mage targets can render a live progress bar instead of scrolling log lines.
+type Progress interface {
+	// Started is called once an artifact's download has acquired a concurrency slot and begins.
+	Started(name, url string)
+	// Bytes is called zero or more times while an artifact is being fetched, with the
+	// cumulative number of bytes written so far (not a delta), so a live progress bar can
+	// track each artifact's completion rather than only learning its final size. An artifact
+	// served from a local cache without copying bytes gets no Bytes calls.
+	Bytes(name, url string, n int64)
+	// Completed is called once an artifact has been fetched and verified successfully.
+	Completed(name, url string)
+	// Failed is called if fetching or verifying an artifact failed.
+	Failed(name, url string, err error)
+}
+
+// noopProgress is the default Progress used when the caller doesn't supply one.
+type noopProgress struct{}
+
+func (noopProgress) Started(name, url string)           {}
+func (noopProgress) Bytes(name, url string, n int64)    {}
+func (noopProgress) Completed(name, url string)         {}
+func (noopProgress) Failed(name, url string, err error) {}
+
+func (o Options) progressOrDefault() Progress {
+	if o.Progress != nil {
+		return o.Progress
+	}
+	return noopProgress{}
+}
+
+func (o Options) concurrency() int {
+	if o.Concurrency > 0 {
+		return o.Concurrency
+	}
+	return runtime.NumCPU() * 2
+}
@@ -0,0 +1,133 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// ResolveOptions controls how ResolveManifestURL locates a build's manifest.
+type ResolveOptions struct {
+	// Staging resolves against staging.elastic.co instead of snapshots.elastic.co.
+	Staging bool
+	// PinnedBuildID, when set, resolves version's manifest for this exact build instead of
+	// whatever "latest" currently points to, so reproducible builds don't shift under a
+	// release that advances mid-CI-run.
+	PinnedBuildID string
+	// Retry controls the backoff schedule used for the resolution request itself.
+	Retry RetryConfig
+}
+
+// latestBuildResponse mirrors the subset of fields ResolveManifestURL needs from
+// https://snapshots.elastic.co/latest/<version>.json (and its staging/pinned-build
+// equivalents).
+type latestBuildResponse struct {
+	BuildID     string `json:"build_id"`
+	ManifestURL string `json:"manifest_url"`
+}
+
+type resolvedBuild struct {
+	buildID     string
+	manifestURL string
+}
+
+// resolveCache holds resolved {host,version,PinnedBuildID} -> manifestURL mappings for the process
+// lifetime, so repeated calls during a single CI run don't re-hit the "latest" endpoint (and,
+// more importantly, so that once "latest" has been observed it can't silently advance to a
+// different build partway through a run).
+var (
+	resolveCacheMu sync.Mutex
+	resolveCache   = map[string]resolvedBuild{}
+)
+
+// ResolveManifestURL resolves the manifest URL for version's current build (or, with
+// opts.PinnedBuildID set, a specific build), for feeding into DownloadManifest. Callers who
+// only have a version string, as is typical in CI, don't need to know the exact manifest URL
+// up front.
+//
+// The resolution is cached in-memory for the process lifetime, keyed by host, version and
+// PinnedBuildID, so "latest" can't advance mid-run out from under a caller that resolved it
+// earlier.
+func ResolveManifestURL(ctx context.Context, version string, opts ResolveOptions) (string, error) {
+	host := "snapshots.elastic.co"
+	if opts.Staging {
+		host = "staging.elastic.co"
+	}
+	cacheKey := fmt.Sprintf("%s|%s|%s", host, version, opts.PinnedBuildID)
+
+	resolveCacheMu.Lock()
+	cached, ok := resolveCache[cacheKey]
+	resolveCacheMu.Unlock()
+	if ok {
+		return cached.manifestURL, nil
+	}
+
+	build := "latest"
+	if opts.PinnedBuildID != "" {
+		build = opts.PinnedBuildID
+	}
+	lookupURL := fmt.Sprintf("https://%s/%s/%s.json", host, build, version)
+
+	fetch := func() (latestBuildResponse, error) { return fetchLatestBuild(ctx, lookupURL) }
+	latest, err := doWithRetries(ctx, opts.Retry, lookupURL, fetch)
+	if err != nil {
+		return "", fmt.Errorf("resolving manifest URL for %s: %w", version, err)
+	}
+
+	manifestURL, err := sanitizeManifestURL(latest.ManifestURL)
+	if err != nil {
+		return "", err
+	}
+
+	resolveCacheMu.Lock()
+	resolveCache[cacheKey] = resolvedBuild{buildID: latest.BuildID, manifestURL: manifestURL}
+	resolveCacheMu.Unlock()
+
+	return manifestURL, nil
+}
+
+func fetchLatestBuild(ctx context.Context, lookupURL string) (latestBuildResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, lookupURL, nil)
+	if err != nil {
+		return latestBuildResponse{}, fmt.Errorf("building request for %s: %w", lookupURL, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return latestBuildResponse{}, fmt.Errorf("fetching %s: %w", lookupURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return latestBuildResponse{}, &HTTPStatusError{StatusCode: resp.StatusCode, URL: lookupURL}
+	}
+	var parsed latestBuildResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return latestBuildResponse{}, fmt.Errorf("decoding %s: %w", lookupURL, err)
+	}
+	return parsed, nil
+}
+
+// sanitizeManifestURL validates url against AllowedManifestHosts, the same check
+// DownloadManifest performs on its input, so the resolver can't be tricked into returning a
+// URL on some other host.
+func sanitizeManifestURL(rawURL string) (string, error) {
+	return sanitizeAllowedURL(rawURL)
+}
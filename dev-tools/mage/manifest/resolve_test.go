@@ -0,0 +1,115 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package manifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// resetResolveCache clears the package-level resolveCache so tests don't leak state into each
+// other or into a future run of the real resolver.
+func resetResolveCache(t *testing.T) {
+	t.Helper()
+	resolveCacheMu.Lock()
+	resolveCache = map[string]resolvedBuild{}
+	resolveCacheMu.Unlock()
+}
+
+func TestResolveManifestURLReusesCachedBuild(t *testing.T) {
+	resetResolveCache(t)
+	t.Cleanup(func() { resetResolveCache(t) })
+
+	cacheKey := fmt.Sprintf("%s|%s|%s", "snapshots.elastic.co", "8.0.0", "")
+	resolveCacheMu.Lock()
+	resolveCache[cacheKey] = resolvedBuild{buildID: "1234", manifestURL: "https://snapshots.elastic.co/8.0.0-1234/manifest.json"}
+	resolveCacheMu.Unlock()
+
+	// With the build already cached, ResolveManifestURL must return it without attempting a
+	// network call - if it fell through to fetchLatestBuild it would fail to resolve
+	// snapshots.elastic.co in this sandbox and return an error here instead.
+	got, err := ResolveManifestURL(context.Background(), "8.0.0", ResolveOptions{})
+	if err != nil {
+		t.Fatalf("ResolveManifestURL: %v", err)
+	}
+	if got != "https://snapshots.elastic.co/8.0.0-1234/manifest.json" {
+		t.Fatalf("got %q, want the cached manifest URL", got)
+	}
+}
+
+func TestResolveManifestURLCacheKeyHonorsStagingHost(t *testing.T) {
+	resetResolveCache(t)
+	t.Cleanup(func() { resetResolveCache(t) })
+
+	snapshotsKey := fmt.Sprintf("%s|%s|%s", "snapshots.elastic.co", "8.0.0", "")
+	stagingKey := fmt.Sprintf("%s|%s|%s", "staging.elastic.co", "8.0.0", "")
+	resolveCacheMu.Lock()
+	resolveCache[snapshotsKey] = resolvedBuild{manifestURL: "https://snapshots.elastic.co/8.0.0/manifest.json"}
+	resolveCache[stagingKey] = resolvedBuild{manifestURL: "https://staging.elastic.co/8.0.0/manifest.json"}
+	resolveCacheMu.Unlock()
+
+	got, err := ResolveManifestURL(context.Background(), "8.0.0", ResolveOptions{Staging: true})
+	if err != nil {
+		t.Fatalf("ResolveManifestURL: %v", err)
+	}
+	if got != "https://staging.elastic.co/8.0.0/manifest.json" {
+		t.Fatalf("got %q, want the staging manifest URL", got)
+	}
+
+	got, err = ResolveManifestURL(context.Background(), "8.0.0", ResolveOptions{})
+	if err != nil {
+		t.Fatalf("ResolveManifestURL: %v", err)
+	}
+	if got != "https://snapshots.elastic.co/8.0.0/manifest.json" {
+		t.Fatalf("got %q, want the snapshots manifest URL", got)
+	}
+}
+
+func TestFetchLatestBuildNonOKStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	_, err := fetchLatestBuild(context.Background(), srv.URL)
+	if err == nil {
+		t.Fatal("expected an error for a non-200 response")
+	}
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected a *HTTPStatusError, got %T: %v", err, err)
+	}
+	if statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d, want %d", statusErr.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestFetchLatestBuildInvalidJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("not json"))
+	}))
+	defer srv.Close()
+
+	if _, err := fetchLatestBuild(context.Background(), srv.URL); err == nil {
+		t.Fatal("expected an error for an invalid JSON response")
+	}
+}
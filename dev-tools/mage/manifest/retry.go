@@ -0,0 +1,165 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package manifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/elastic/elastic-agent/dev-tools/mage/manifest/downloader"
+)
+
+// RetryConfig controls the backoff schedule doWithRetries uses when retrying a failed
+// manifest or artifact download. The zero value (RetryConfig{}) uses sensible defaults, so
+// callers who don't care can just pass RetryConfig{}.
+type RetryConfig struct {
+	InitialInterval     time.Duration
+	MaxInterval         time.Duration
+	MaxElapsedTime      time.Duration
+	Multiplier          float64
+	RandomizationFactor float64
+	MaxRetries          int
+}
+
+func (c RetryConfig) withDefaults() RetryConfig {
+	if c.InitialInterval <= 0 {
+		c.InitialInterval = 1 * time.Second
+	}
+	if c.MaxInterval <= 0 {
+		c.MaxInterval = 10 * time.Second
+	}
+	if c.MaxElapsedTime <= 0 {
+		c.MaxElapsedTime = 30 * time.Second
+	}
+	if c.Multiplier <= 0 {
+		c.Multiplier = 3
+	}
+	if c.RandomizationFactor <= 0 {
+		c.RandomizationFactor = 0.2
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = 3
+	}
+	return c
+}
+
+// nextDelay returns the delay before the attempt-th retry (0-indexed), as
+// min(MaxInterval, InitialInterval*Multiplier^attempt) scaled by a uniform random factor in
+// [1-RandomizationFactor, 1+RandomizationFactor].
+func (c RetryConfig) nextDelay(attempt int) time.Duration {
+	backoff := float64(c.InitialInterval) * math.Pow(c.Multiplier, float64(attempt))
+	if maxBackoff := float64(c.MaxInterval); backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	low := backoff * (1 - c.RandomizationFactor)
+	high := backoff * (1 + c.RandomizationFactor)
+	return time.Duration(low + rand.Float64()*(high-low))
+}
+
+// HTTPStatusError carries the HTTP status code of a failed download attempt so doWithRetries
+// can tell a retryable transport hiccup (5xx, 408, 429) apart from a permanent failure (any
+// other 4xx). It is defined in the downloader package so the http Downloader can return it
+// without importing manifest; it's aliased here since every HTTP call in this package (not
+// just the Downloader) needs to construct one.
+type HTTPStatusError = downloader.HTTPStatusError
+
+// RetryAttempt records the outcome of one attempt made by doWithRetries.
+type RetryAttempt struct {
+	StatusCode int
+	Err        error
+	Elapsed    time.Duration
+}
+
+// RetryError is returned once doWithRetries gives up, listing every attempt's HTTP status and
+// elapsed time so CI failures are diagnosable without re-running with -v.
+type RetryError struct {
+	URL      string
+	Attempts []RetryAttempt
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("giving up after %d attempt(s) for %s: %v", len(e.Attempts), e.URL, e.Unwrap())
+}
+
+func (e *RetryError) Unwrap() error {
+	if len(e.Attempts) == 0 {
+		return nil
+	}
+	return e.Attempts[len(e.Attempts)-1].Err
+}
+
+func isRetryable(err error) bool {
+	if errors.Is(err, invalidManifestURL) || errors.Is(err, notAllowedManifestURL) {
+		return false
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.StatusCode == http.StatusRequestTimeout || statusErr.StatusCode == http.StatusTooManyRequests {
+			return true
+		}
+		if statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 {
+			return false
+		}
+	}
+	return true
+}
+
+// doWithRetries calls f, retrying with exponential backoff and jitter per cfg until it
+// succeeds, a non-retryable error comes back, ctx is done, or cfg's retry budget is
+// exhausted. url is only used to label the RetryError returned when it gives up.
+func doWithRetries[T any](ctx context.Context, cfg RetryConfig, url string, f func() (T, error)) (T, error) {
+	cfg = cfg.withDefaults()
+	start := time.Now()
+	var zero T
+	var attempts []RetryAttempt
+
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		default:
+		}
+
+		result, err := f()
+		if err == nil {
+			return result, nil
+		}
+
+		statusCode := 0
+		var statusErr *HTTPStatusError
+		if errors.As(err, &statusErr) {
+			statusCode = statusErr.StatusCode
+		}
+		attempts = append(attempts, RetryAttempt{StatusCode: statusCode, Err: err, Elapsed: time.Since(start)})
+
+		if !isRetryable(err) || attempt >= cfg.MaxRetries-1 || time.Since(start) >= cfg.MaxElapsedTime {
+			return zero, &RetryError{URL: url, Attempts: attempts}
+		}
+
+		select {
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		case <-time.After(cfg.nextDelay(attempt)):
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package manifest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "plain error is retryable", err: errors.New("boom"), want: true},
+		{name: "invalid manifest URL is not retryable", err: invalidManifestURL, want: false},
+		{name: "disallowed manifest URL is not retryable", err: notAllowedManifestURL, want: false},
+		{name: "404 is not retryable", err: &HTTPStatusError{StatusCode: http.StatusNotFound}, want: false},
+		{name: "500 is retryable", err: &HTTPStatusError{StatusCode: http.StatusInternalServerError}, want: true},
+		{name: "408 is retryable", err: &HTTPStatusError{StatusCode: http.StatusRequestTimeout}, want: true},
+		{name: "429 is retryable", err: &HTTPStatusError{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "wrapped 403 is not retryable", err: wrapErr(&HTTPStatusError{StatusCode: http.StatusForbidden}), want: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isRetryable(tc.err); got != tc.want {
+				t.Fatalf("isRetryable(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func wrapErr(err error) error {
+	return errors.Join(err)
+}
+
+func TestRetryConfigNextDelay(t *testing.T) {
+	cfg := RetryConfig{
+		InitialInterval:     time.Second,
+		MaxInterval:         10 * time.Second,
+		Multiplier:          3,
+		RandomizationFactor: 0.2,
+	}.withDefaults()
+
+	for attempt := 0; attempt < 5; attempt++ {
+		d := cfg.nextDelay(attempt)
+		if d <= 0 {
+			t.Fatalf("attempt %d: expected a positive delay, got %v", attempt, d)
+		}
+		if d > cfg.MaxInterval*2 {
+			t.Fatalf("attempt %d: delay %v exceeds MaxInterval*2 even with jitter", attempt, d)
+		}
+	}
+}
+
+func TestRetryConfigNextDelayCapsAtMaxInterval(t *testing.T) {
+	cfg := RetryConfig{
+		InitialInterval:     time.Second,
+		MaxInterval:         2 * time.Second,
+		Multiplier:          10,
+		RandomizationFactor: 0,
+	}.withDefaults()
+
+	d := cfg.nextDelay(5)
+	if d != cfg.MaxInterval {
+		t.Fatalf("expected delay to cap at MaxInterval %v, got %v", cfg.MaxInterval, d)
+	}
+}
+
+func TestDoWithRetriesSucceedsAfterRetryableFailures(t *testing.T) {
+	cfg := RetryConfig{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, MaxRetries: 5}
+	attempts := 0
+	got, err := doWithRetries(context.Background(), cfg, "https://example.test/x", func() (string, error) {
+		attempts++
+		if attempts < 3 {
+			return "", &HTTPStatusError{StatusCode: http.StatusInternalServerError}
+		}
+		return "ok", nil
+	})
+	if err != nil {
+		t.Fatalf("doWithRetries: %v", err)
+	}
+	if got != "ok" {
+		t.Fatalf("got %q, want %q", got, "ok")
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestDoWithRetriesStopsOnNonRetryableError(t *testing.T) {
+	cfg := RetryConfig{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, MaxRetries: 5}
+	attempts := 0
+	_, err := doWithRetries(context.Background(), cfg, "https://example.test/x", func() (string, error) {
+		attempts++
+		return "", &HTTPStatusError{StatusCode: http.StatusNotFound}
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if attempts != 1 {
+		t.Fatalf("expected exactly 1 attempt for a non-retryable error, got %d", attempts)
+	}
+}
+
+func TestDoWithRetriesExhaustsMaxRetries(t *testing.T) {
+	cfg := RetryConfig{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, MaxRetries: 3}
+	attempts := 0
+	_, err := doWithRetries(context.Background(), cfg, "https://example.test/x", func() (string, error) {
+		attempts++
+		return "", &HTTPStatusError{StatusCode: http.StatusInternalServerError}
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if attempts != cfg.MaxRetries {
+		t.Fatalf("expected %d attempts, got %d", cfg.MaxRetries, attempts)
+	}
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("expected a *RetryError, got %T", err)
+	}
+	if len(retryErr.Attempts) != cfg.MaxRetries {
+		t.Fatalf("expected %d recorded attempts, got %d", cfg.MaxRetries, len(retryErr.Attempts))
+	}
+}
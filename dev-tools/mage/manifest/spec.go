@@ -0,0 +1,96 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package manifest
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed components.spec.yml
+var defaultComponentSpecData []byte
+
+// ComponentSpec describes one bundled component DownloadComponentsFromManifest fetches from
+// a manifest.
+type ComponentSpec struct {
+	// Project is the manifest project key (e.g. "beats") this component's packages are
+	// resolved from.
+	Project string `yaml:"project"`
+	// Packages are the sub-package names to fetch from Project (e.g. "auditbeat",
+	// "filebeat" for the "beats" project). Each one doubles as the artifact's filename
+	// prefix, which may differ from Project itself, as endpoint-security (resolved from
+	// the "endpoint-dev" project) does.
+	Packages []string `yaml:"packages"`
+	// Artifact, when set, overrides the filename prefix used to resolve every package in
+	// Packages, for components whose filename prefix in the manifest matches neither
+	// Project nor the Packages entry itself. Most components don't need this: Project
+	// already differs freely from Packages (endpoint-security's project is "endpoint-dev"),
+	// so Artifact only matters for the rarer case where a third, distinct name is needed.
+	Artifact string `yaml:"artifact,omitempty"`
+	// Platforms, when non-empty, restricts this component to the listed platforms. Some
+	// components, like endpoint-security, aren't built for every platform.
+	Platforms []string `yaml:"platforms,omitempty"`
+	// Required fails the whole download hard when a package in this component is missing
+	// from the manifest for a requested platform, instead of just logging a warning.
+	Required bool `yaml:"required"`
+}
+
+// ComponentSpecs is a parsed components.spec.yml: the set of components
+// DownloadComponentsFromManifest fetches from a manifest.
+type ComponentSpecs struct {
+	Components []ComponentSpec `yaml:"components"`
+}
+
+func parseComponentSpecs(data []byte) (ComponentSpecs, error) {
+	var specs ComponentSpecs
+	if err := yaml.Unmarshal(data, &specs); err != nil {
+		return ComponentSpecs{}, fmt.Errorf("parsing component spec: %w", err)
+	}
+	return specs, nil
+}
+
+// appliesToPlatform reports whether c should be fetched for platform: true when Platforms is
+// empty (no restriction) or platform is explicitly listed.
+func (c ComponentSpec) appliesToPlatform(platform string) bool {
+	if len(c.Platforms) == 0 {
+		return true
+	}
+	for _, p := range c.Platforms {
+		if p == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// loadComponentSpecs resolves the ComponentSpecs to use for a run: the file at o.SpecFile if
+// set, else the spec embedded into the binary, which matches the historical hard-coded
+// component list.
+func (o Options) loadComponentSpecs() (ComponentSpecs, error) {
+	if o.SpecFile != "" {
+		data, err := os.ReadFile(o.SpecFile)
+		if err != nil {
+			return ComponentSpecs{}, fmt.Errorf("reading component spec %s: %w", o.SpecFile, err)
+		}
+		return parseComponentSpecs(data)
+	}
+	return parseComponentSpecs(defaultComponentSpecData)
+}
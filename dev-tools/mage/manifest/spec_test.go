@@ -0,0 +1,99 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package manifest
+
+import (
+	"testing"
+
+	"github.com/elastic/elastic-agent/pkg/testing/tools"
+)
+
+func TestParseComponentSpecs(t *testing.T) {
+	data := []byte(`
+components:
+  - project: beats
+    packages: [auditbeat, filebeat]
+  - project: endpoint-dev
+    packages: [endpoint-security]
+    artifact: endpoint-security-agent
+    platforms: [linux/amd64]
+    required: true
+`)
+	specs, err := parseComponentSpecs(data)
+	if err != nil {
+		t.Fatalf("parseComponentSpecs: %v", err)
+	}
+	if len(specs.Components) != 2 {
+		t.Fatalf("expected 2 components, got %d", len(specs.Components))
+	}
+	endpoint := specs.Components[1]
+	if endpoint.Artifact != "endpoint-security-agent" {
+		t.Fatalf("expected artifact override, got %q", endpoint.Artifact)
+	}
+	if !endpoint.Required {
+		t.Fatal("expected endpoint-dev component to be required")
+	}
+	if !endpoint.appliesToPlatform("linux/amd64") {
+		t.Fatal("expected endpoint-dev component to apply to linux/amd64")
+	}
+	if endpoint.appliesToPlatform("windows/amd64") {
+		t.Fatal("expected endpoint-dev component not to apply to windows/amd64")
+	}
+}
+
+func TestComponentSpecAppliesToPlatformWithNoRestriction(t *testing.T) {
+	spec := ComponentSpec{Project: "beats", Packages: []string{"filebeat"}}
+	if !spec.appliesToPlatform("linux/amd64") {
+		t.Fatal("expected a component with no Platforms restriction to apply to every platform")
+	}
+}
+
+func TestResolveManifestPackageDefaultsToPkgAsPrefix(t *testing.T) {
+	project := tools.Project{
+		Packages: map[string]tools.Package{
+			"filebeat-8.0.0-linux-x86_64.tar.gz": {URL: "u", ShaURL: "s", AscURL: "a"},
+		},
+	}
+	got := resolveManifestPackage(project, "filebeat", "", "linux-x86_64.tar.gz", "8.0.0")
+	if got == nil {
+		t.Fatal("expected a match using pkg as the filename prefix")
+	}
+}
+
+func TestResolveManifestPackageHonorsArtifactOverride(t *testing.T) {
+	project := tools.Project{
+		Packages: map[string]tools.Package{
+			"endpoint-security-agent-8.0.0-linux-x86_64.tar.gz": {URL: "u", ShaURL: "s", AscURL: "a"},
+		},
+	}
+	// pkg alone ("endpoint-security") wouldn't match; only the Artifact override does.
+	if got := resolveManifestPackage(project, "endpoint-security", "", "linux-x86_64.tar.gz", "8.0.0"); got != nil {
+		t.Fatalf("expected no match without the artifact override, got %v", got)
+	}
+	got := resolveManifestPackage(project, "endpoint-security", "endpoint-security-agent", "linux-x86_64.tar.gz", "8.0.0")
+	if got == nil {
+		t.Fatal("expected a match using the artifact override as the filename prefix")
+	}
+}
+
+func TestResolveManifestPackageMissing(t *testing.T) {
+	project := tools.Project{Packages: map[string]tools.Package{}}
+	if got := resolveManifestPackage(project, "filebeat", "", "linux-x86_64.tar.gz", "8.0.0"); got != nil {
+		t.Fatalf("expected nil for a missing package, got %v", got)
+	}
+}
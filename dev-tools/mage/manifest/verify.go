@@ -0,0 +1,205 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// defaultKeyring is a placeholder test fixture, not the real Elastic release signing key:
+// production callers must set VerifyOptions.KeyringPath to the actual Elastic release
+// keyring (e.g. https://artifacts.elastic.co/GPG-KEY-elasticsearch) or signature
+// verification will reject every artifact.
+//
+//go:embed keys/elastic-release-pgp.asc
+var defaultKeyring []byte
+
+// ErrVerificationFailed is returned when a downloaded artifact fails its checksum or
+// signature check. Callers can use errors.Is to distinguish it from the transport failures
+// doWithRetries already returns.
+var ErrVerificationFailed = errors.New("artifact verification failed")
+
+// VerifyOptions controls how downloaded artifacts are checked for tampering or corruption
+// before DownloadComponentsFromManifest hands them off for packaging.
+type VerifyOptions struct {
+	// SkipGPG disables signature verification, leaving only the SHA-512 checksum check.
+	SkipGPG bool
+	// KeyringPath, when set, overrides the embedded default keyring, which is a placeholder
+	// test fixture rather than the Elastic release key (see defaultKeyring).
+	KeyringPath string
+	// Verifier, when set, is used instead of the default SHA-512+GPG verifier.
+	Verifier Verifier
+}
+
+// Verifier checks a downloaded artifact against the raw contents of its .sha512 and .asc
+// sidecar files, both of which must be fetched fresh for every verification since a poisoned
+// local cache would otherwise validate against itself.
+type Verifier interface {
+	Verify(path string, sha512Contents, ascContents []byte) error
+}
+
+// defaultVerifier checks the SHA-512 checksum and, unless SkipGPG is set, a detached GPG
+// signature against a keyring.
+type defaultVerifier struct {
+	skipGPG bool
+	keyring openpgp.EntityList
+}
+
+func newDefaultVerifier(opts VerifyOptions) (Verifier, error) {
+	if opts.Verifier != nil {
+		return opts.Verifier, nil
+	}
+	v := &defaultVerifier{skipGPG: opts.SkipGPG}
+	if opts.SkipGPG {
+		return v, nil
+	}
+
+	keyData := defaultKeyring
+	if opts.KeyringPath != "" {
+		data, err := os.ReadFile(opts.KeyringPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading keyring %s: %w", opts.KeyringPath, err)
+		}
+		keyData = data
+	}
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(keyData))
+	if err != nil {
+		return nil, fmt.Errorf("parsing keyring: %w", err)
+	}
+	v.keyring = keyring
+	return v, nil
+}
+
+func (v *defaultVerifier) Verify(path string, sha512Contents, ascContents []byte) error {
+	payload, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening %s for verification: %w", path, err)
+	}
+	defer payload.Close()
+
+	hasher := sha512.New()
+	if _, err := io.Copy(hasher, payload); err != nil {
+		return fmt.Errorf("hashing %s: %w", path, err)
+	}
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	expectedSum, err := parseSha512Sidecar(sha512Contents)
+	if err != nil {
+		return err
+	}
+	if expectedSum != sum {
+		return fmt.Errorf("%w: sha512 mismatch for %s", ErrVerificationFailed, path)
+	}
+
+	if v.skipGPG {
+		return nil
+	}
+	if _, err := payload.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("rewinding %s: %w", path, err)
+	}
+	if _, err := openpgp.CheckDetachedSignature(v.keyring, payload, bytes.NewReader(ascContents), nil); err != nil {
+		return fmt.Errorf("%w: signature check for %s: %v", ErrVerificationFailed, path, err)
+	}
+	return nil
+}
+
+// verifyDownloadedArtifact fetches the sha512/asc sidecars for target fresh from the manifest
+// host and verifies target against them, deleting it on failure so a half-trusted artifact is
+// never left behind for packaging to pick up. The sidecar fetches go through retry, same as
+// every other HTTP call in this package, so a transient 5xx on this small GET doesn't fail an
+// artifact whose (possibly much larger) download already succeeded.
+func verifyDownloadedArtifact(ctx context.Context, target, shaURL, ascURL string, retry RetryConfig, opts VerifyOptions) (err error) {
+	defer func() {
+		if err == nil {
+			return
+		}
+		if rmErr := os.Remove(target); rmErr != nil && !os.IsNotExist(rmErr) {
+			err = fmt.Errorf("%w (and failed to remove unverified payload: %v)", err, rmErr)
+		}
+	}()
+
+	verifier, err := newDefaultVerifier(opts)
+	if err != nil {
+		return fmt.Errorf("preparing verifier: %w", err)
+	}
+
+	shaFetch := func() ([]byte, error) { return fetchVerificationSidecar(ctx, shaURL) }
+	shaContents, err := doWithRetries(ctx, retry, shaURL, shaFetch)
+	if err != nil {
+		return fmt.Errorf("fetching sha512 sidecar: %w", err)
+	}
+
+	var ascContents []byte
+	if !opts.SkipGPG {
+		ascFetch := func() ([]byte, error) { return fetchVerificationSidecar(ctx, ascURL) }
+		ascContents, err = doWithRetries(ctx, retry, ascURL, ascFetch)
+		if err != nil {
+			return fmt.Errorf("fetching asc sidecar: %w", err)
+		}
+	}
+
+	return verifier.Verify(target, shaContents, ascContents)
+}
+
+// parseSha512Sidecar extracts the checksum from the contents of a .sha512 file, which is
+// formatted as "<hex digest>  <filename>" per the shasum(1) convention.
+func parseSha512Sidecar(contents []byte) (string, error) {
+	fields := strings.Fields(string(contents))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("%w: empty sha512 sidecar", ErrVerificationFailed)
+	}
+	return fields[0], nil
+}
+
+// fetchExpectedSha512 fetches and parses the sha512 sidecar for url, for callers (such as the
+// fs downloader) that need the expected checksum to key a cache lookup before the artifact
+// itself has been fetched.
+func fetchExpectedSha512(ctx context.Context, shaURL string) (string, error) {
+	contents, err := fetchVerificationSidecar(ctx, shaURL)
+	if err != nil {
+		return "", fmt.Errorf("fetching sha512 sidecar: %w", err)
+	}
+	return parseSha512Sidecar(contents)
+}
+
+func fetchVerificationSidecar(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request for %s: %w", url, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, URL: url}
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,125 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+func TestDefaultKeyringParses(t *testing.T) {
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(defaultKeyring))
+	if err != nil {
+		t.Fatalf("parsing embedded default keyring: %v", err)
+	}
+	if len(keyring) == 0 {
+		t.Fatal("embedded default keyring contains no entities")
+	}
+}
+
+func TestNewDefaultVerifier(t *testing.T) {
+	v, err := newDefaultVerifier(VerifyOptions{})
+	if err != nil {
+		t.Fatalf("newDefaultVerifier with default keyring: %v", err)
+	}
+	if v == nil {
+		t.Fatal("expected a non-nil verifier")
+	}
+}
+
+func TestNewDefaultVerifierSkipGPG(t *testing.T) {
+	v, err := newDefaultVerifier(VerifyOptions{SkipGPG: true})
+	if err != nil {
+		t.Fatalf("newDefaultVerifier with SkipGPG: %v", err)
+	}
+	dv, ok := v.(*defaultVerifier)
+	if !ok {
+		t.Fatalf("expected *defaultVerifier, got %T", v)
+	}
+	if !dv.skipGPG {
+		t.Fatal("expected skipGPG to be true")
+	}
+}
+
+func TestVerifyDownloadedArtifactRetriesSidecarFetch(t *testing.T) {
+	const payload = "artifact contents"
+	target := filepath.Join(t.TempDir(), "artifact.tar.gz")
+	if err := os.WriteFile(target, []byte(payload), 0644); err != nil {
+		t.Fatalf("writing target artifact: %v", err)
+	}
+	sum := "4bc56a8406aa4d3a0a73dcd8a104764527ec4b2e3af34d1abd2b23097412dd9c97f04fbbd84e9fbe8a1e1abb9a03d9efb47ec37446d3f226b7d44b324060dc0b"
+
+	var shaAttempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&shaAttempts, 1) == 1 {
+			http.Error(w, "please retry", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(sum))
+	}))
+	defer srv.Close()
+
+	retry := RetryConfig{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, MaxRetries: 3}
+	err := verifyDownloadedArtifact(context.Background(), target, srv.URL, srv.URL, retry, VerifyOptions{SkipGPG: true})
+	if err != nil {
+		t.Fatalf("verifyDownloadedArtifact: %v", err)
+	}
+	if shaAttempts != 2 {
+		t.Fatalf("expected the sha512 sidecar fetch to be retried once, got %d attempts", shaAttempts)
+	}
+}
+
+func TestParseSha512Sidecar(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{name: "shasum format", in: "deadbeef  filebeat-8.0.0-linux-x86_64.tar.gz\n", want: "deadbeef"},
+		{name: "digest only", in: "deadbeef", want: "deadbeef"},
+		{name: "empty", in: "", wantErr: true},
+		{name: "whitespace only", in: "   \n", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseSha512Sidecar([]byte(tc.in))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got sum %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}